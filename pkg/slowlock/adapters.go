@@ -0,0 +1,76 @@
+package slowlock
+
+import (
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogrusAdapter returns a StructuredLogFunction that reports a SlowLockEvent as a single
+// warning-level entry through logger, with one field per piece of typed information.
+func LogrusAdapter(logger *logrus.Logger) StructuredLogFunction {
+	return func(event SlowLockEvent) {
+		logger.WithFields(slowLockEventFields(event)).Warn("slow lock")
+	}
+}
+
+// slowLockEventFields converts a SlowLockEvent into logrus.Fields, omitting fields that weren't
+// populated for this event.
+func slowLockEventFields(event SlowLockEvent) logrus.Fields {
+	fields := logrus.Fields{
+		"annotation": event.Annotation,
+		"elapsed":    event.Elapsed,
+		"attempt":    event.Attempt,
+	}
+	if event.GoroutineID != 0 {
+		fields["goroutine_id"] = event.GoroutineID
+	}
+	if event.CallerInfo != nil {
+		fields["file"] = event.CallerInfo.File
+		fields["line"] = event.CallerInfo.Line
+	}
+	if event.GroupID != 0 {
+		fields["group_id"] = event.GroupID
+	}
+	if event.Holder != nil {
+		fields["holder_goroutine_id"] = event.Holder.GoroutineID
+		fields["holder_since"] = event.Holder.AcquireTime
+		if event.Holder.CallerInfo.File != "" {
+			fields["holder_file"] = event.Holder.CallerInfo.File
+			fields["holder_line"] = event.Holder.CallerInfo.Line
+		}
+	}
+	return fields
+}
+
+// SlogAdapter returns a StructuredLogFunction that reports a SlowLockEvent as a single
+// warning-level record through logger, with one attribute per piece of typed information.
+func SlogAdapter(logger *slog.Logger) StructuredLogFunction {
+	return func(event SlowLockEvent) {
+		attrs := []any{
+			slog.String("annotation", event.Annotation),
+			slog.Duration("elapsed", event.Elapsed),
+			slog.Int("attempt", event.Attempt),
+		}
+		if event.GoroutineID != 0 {
+			attrs = append(attrs, slog.Int("goroutine_id", event.GoroutineID))
+		}
+		if event.CallerInfo != nil {
+			attrs = append(attrs, slog.String("file", event.CallerInfo.File), slog.Int("line", event.CallerInfo.Line))
+		}
+		if event.GroupID != 0 {
+			attrs = append(attrs, slog.Uint64("group_id", event.GroupID))
+		}
+		if event.Holder != nil {
+			attrs = append(attrs,
+				slog.Int("holder_goroutine_id", event.Holder.GoroutineID),
+				slog.Time("holder_since", event.Holder.AcquireTime))
+			if event.Holder.CallerInfo.File != "" {
+				attrs = append(attrs,
+					slog.String("holder_file", event.Holder.CallerInfo.File),
+					slog.Int("holder_line", event.Holder.CallerInfo.Line))
+			}
+		}
+		logger.Warn("slow lock", attrs...)
+	}
+}
@@ -0,0 +1,43 @@
+package slowlock
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogrusAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	slf := LogrusAdapter(logger)
+	slf(SlowLockEvent{
+		Annotation: "test",
+		Elapsed:    50 * time.Millisecond,
+		Attempt:    1,
+	})
+
+	assert.Contains(t, buf.String(), `"annotation":"test"`)
+	assert.Contains(t, buf.String(), `"msg":"slow lock"`)
+}
+
+func TestSlogAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	slf := SlogAdapter(logger)
+	slf(SlowLockEvent{
+		Annotation: "test",
+		Elapsed:    50 * time.Millisecond,
+		Attempt:    1,
+	})
+
+	assert.Contains(t, buf.String(), `"annotation":"test"`)
+	assert.Contains(t, buf.String(), `"msg":"slow lock"`)
+}
@@ -1,10 +1,29 @@
+// Package slowlock provides drop-in replacements for sync.Mutex and sync.RWMutex that log when
+// a lock acquisition takes longer than expected.
+//
+// Tracking is controlled process-wide by three environment variables, read once at process
+// start:
+//
+//   - SLOWLOCK_ENABLED: parsed with strconv.ParseBool; if it parses and is false, tracking
+//     starts disabled (see SetEnabled).
+//   - SLOWLOCK_TIMEOUT: parsed with time.ParseDuration; if valid, becomes the initial default
+//     timeout (see SetDefaultTimeout).
+//   - SLOWLOCK_LOGLEVEL: "basic" or "verbose" (case-insensitive); sets the initial default log
+//     level (see SetDefaultLogLevel).
 package slowlock
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,13 +32,40 @@ type CallerInfo struct {
 	Line int
 }
 
+// HolderInfo describes the goroutine currently holding a lock: where it acquired the lock,
+// when, and (if it can still be found when the slow-lock log fires) its stack trace.
+type HolderInfo struct {
+	CallerInfo  CallerInfo
+	AcquireTime time.Time
+	GoroutineID int
+	Stack       string
+}
+
 type LogData struct {
 	Annotation string
 	StartTime  time.Time
 	CallerInfo *CallerInfo
+
+	// Holder is the goroutine currently holding the lock, captured at the moment the slow-lock
+	// log fired. It is nil if no exclusive holder was recorded at that moment (for example, the
+	// lock is held only by readers, or was taken with TryLock).
+	Holder *HolderInfo
+
+	// GroupID identifies a LockAll/LockAllContext group this acquisition is part of, so that
+	// logs for different sub-locks of the same bulk acquisition can be correlated. It is 0 for
+	// locks acquired individually.
+	GroupID uint64
+
+	// GoroutineID is the ID of the goroutine that is waiting to acquire the lock (not the
+	// holder's), captured lazily the first time a slow-lock log actually fires for this
+	// acquisition, not on every Lock/RLock call. It is 0 on a LogData that never reaches a
+	// LogFunction/StructuredLogFunction.
+	GoroutineID int
 }
 
-// String returns a string representation of the log data.
+// String returns a string representation of the log data. This is the legacy formatted-message
+// path, used by the default LogFunction (printLog) and available to any custom LogFunction;
+// prefer Config.StructuredLogFunction for new code that wants typed fields instead of a string.
 func (ld *LogData) String() string {
 	var descr string
 	if ld.Annotation != "" {
@@ -32,15 +78,129 @@ func (ld *LogData) String() string {
 		descr,
 		ld.StartTime,
 		time.Now().Sub(ld.StartTime))
+	if ld.GroupID != 0 {
+		msg += fmt.Sprintf(" (group %d)", ld.GroupID)
+	}
+	if ld.Holder != nil {
+		var hdescr string
+		if ld.Holder.CallerInfo.File != "" {
+			hdescr = fmt.Sprintf(" at %s line %d", ld.Holder.CallerInfo.File, ld.Holder.CallerInfo.Line)
+		}
+		msg += fmt.Sprintf(". Currently held by goroutine %d%s since %s", ld.Holder.GoroutineID, hdescr, ld.Holder.AcquireTime)
+	}
 	return msg
 }
 
+// goroutineID returns the ID of the calling goroutine, parsed from a small runtime.Stack
+// dump, following the same approach as syncthing's loggedMutex. It returns -1 if the ID
+// cannot be parsed.
+func goroutineID() int {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// stackForGoroutine returns the stack trace text for the goroutine with the given ID,
+// captured from a dump of all goroutines. It returns "" if that goroutine can no longer be
+// found, which will normally be the case once it has finished running.
+func stackForGoroutine(id int) string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := fmt.Sprintf("goroutine %d ", id)
+	for _, block := range strings.Split(string(buf), "\n\n") {
+		if strings.HasPrefix(block, prefix) {
+			return block
+		}
+	}
+	return ""
+}
+
+// newHolderInfo builds a HolderInfo for the calling goroutine, which is assumed to be the one
+// that just acquired the lock.
+func newHolderInfo(ci *CallerInfo) *HolderInfo {
+	h := HolderInfo{
+		AcquireTime: time.Now(),
+		GoroutineID: goroutineID(),
+	}
+	if ci != nil {
+		h.CallerInfo = *ci
+	}
+	return &h
+}
+
 // LogFunction is the type of callback that will be called when a slow lock is detected
 type LogFunction func(data LogData, lastSuccessful *LogData)
 
+// SlowLockEvent carries the same information as LogData, as typed fields rather than a
+// pre-formatted message, for consumers that want to emit a structured log record. See
+// StructuredLogFunction.
+type SlowLockEvent struct {
+	Annotation  string
+	CallerInfo  *CallerInfo
+	GoroutineID int
+	Elapsed     time.Duration
+	Holder      *HolderInfo
+	GroupID     uint64
+
+	// Attempt is the 1-based count of how many times this acquisition has now been reported;
+	// see Config.MaxLogCount. Consumers that want the old behavior of one report per Timeout for
+	// as long as the lock is held should set MaxLogCount to a negative value and use Attempt to
+	// rate-limit on their side instead.
+	Attempt int
+}
+
+// StructuredLogFunction is the type of callback that will be called when a slow lock is
+// detected, if Config.StructuredLogFunction is set. Unlike LogFunction, it is not given the
+// last successful acquisition's LogData, since lastSuccessful.String() is part of the legacy
+// formatted-message path; callers that want it can track it themselves from prior events.
+type StructuredLogFunction func(event SlowLockEvent)
+
+// LogLevel controls how much detail the default log function (printLog) includes in a
+// slow-lock message.
+type LogLevel int
+
+const (
+	// LogLevelBasic logs the waiting caller, the elapsed wait, and who currently holds the
+	// lock (if known), but not a full stack trace.
+	LogLevelBasic LogLevel = iota
+	// LogLevelVerbose additionally includes the current holder's stack trace, captured at the
+	// moment the slow-lock log fires.
+	LogLevelVerbose
+)
+
+// parseLogLevel parses the SLOWLOCK_LOGLEVEL environment variable value, case-insensitively.
+func parseLogLevel(s string) (LogLevel, bool) {
+	switch strings.ToLower(s) {
+	case "basic":
+		return LogLevelBasic, true
+	case "verbose":
+		return LogLevelVerbose, true
+	default:
+		return LogLevelBasic, false
+	}
+}
+
 // printLog prints to the default logger
 func printLog(data LogData, lastSuccessful *LogData) {
 	msg := data.String()
+	if defaultLogLevel == LogLevelVerbose && data.Holder != nil && data.Holder.Stack != "" {
+		msg += "\n" + data.Holder.Stack
+	}
 	if lastSuccessful != nil {
 		msg = msg + ". Last successful: " + lastSuccessful.String()
 	}
@@ -50,8 +210,29 @@ func printLog(data LogData, lastSuccessful *LogData) {
 var (
 	defaultTimeout     time.Duration = 10 * time.Second
 	defaultLogFunction LogFunction   = printLog
+	defaultLogLevel    LogLevel      = LogLevelBasic
+	trackingEnabled    atomic.Bool
 )
 
+func init() {
+	trackingEnabled.Store(true)
+	if v, ok := os.LookupEnv("SLOWLOCK_ENABLED"); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			trackingEnabled.Store(b)
+		}
+	}
+	if v := os.Getenv("SLOWLOCK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			defaultTimeout = d
+		}
+	}
+	if v := os.Getenv("SLOWLOCK_LOGLEVEL"); v != "" {
+		if lvl, ok := parseLogLevel(v); ok {
+			defaultLogLevel = lvl
+		}
+	}
+}
+
 // SetDefaultTimeout sets the default timeout before a lock is considered to be slow.
 func SetDefaultTimeout(t time.Duration) {
 	defaultTimeout = t
@@ -62,11 +243,61 @@ func SetDefaultLogFunction(lf LogFunction) {
 	defaultLogFunction = lf
 }
 
+// SetDefaultLogLevel sets the verbosity of the built-in default log function (printLog). It has
+// no effect on a custom LogFunction set via SetDefaultLogFunction or Config.LogFunction, which
+// are free to use LogData's fields however they like.
+func SetDefaultLogLevel(lvl LogLevel) {
+	defaultLogLevel = lvl
+}
+
+// Enabled reports whether slow-lock tracking is currently enabled process-wide.
+func Enabled() bool {
+	return trackingEnabled.Load()
+}
+
+// SetEnabled enables or disables slow-lock tracking process-wide at runtime. While disabled,
+// Lock/RLock become zero-overhead pass-throughs to the underlying sync.Mutex/sync.RWMutex: no
+// watcher goroutine is spawned, and no time.Now()/runtime.Caller calls are made.
+// LockContext/RLockContext still have to wait on a cancellable select, since a plain
+// sync.Mutex/sync.RWMutex offers no way to do that, but they too skip all tracking overhead
+// beyond that. It can also be set at process start with the SLOWLOCK_ENABLED environment
+// variable. The fast path is checked with an atomic load, so it's safe to flip at any time.
+func SetEnabled(b bool) {
+	trackingEnabled.Store(b)
+}
+
 // Config is an optional type that allows individual configuration of locks.
 type Config struct {
 	Annotation  string
 	Timeout     time.Duration
 	LogFunction LogFunction
+
+	// StructuredLogFunction, if set, is used instead of LogFunction to report a slow-lock
+	// acquisition, as a SlowLockEvent of typed fields rather than a pre-formatted LogData. See
+	// LogrusAdapter and SlogAdapter for adapters to common structured logging libraries.
+	StructuredLogFunction StructuredLogFunction
+
+	// MaxLogCount caps how many times a single slow-lock acquisition is reported: a positive
+	// value reports that many times and then falls silent for the rest of the wait; a negative
+	// value reports indefinitely, once per Timeout/RepeatInterval, for as long as the
+	// acquisition is still waiting (the behavior this package used before MaxLogCount existed).
+	// Zero, the default, reports once.
+	MaxLogCount int
+
+	// RepeatInterval is the interval between the second and subsequent reports of a slow-lock
+	// acquisition, once Timeout has already elapsed once. It has no effect unless MaxLogCount is
+	// nonzero. If zero, Timeout is reused as the repeat interval.
+	RepeatInterval time.Duration
+
+	// ReapOnCancel controls what happens when a *Context call's ctx is canceled before the
+	// underlying lock is acquired. Since a sync.Mutex/sync.RWMutex cannot be interrupted once
+	// Lock/RLock has been called, that call keeps running in the background even after
+	// LockContext/RLockContext has returned ctx.Err(). If ReapOnCancel is true, a reaper
+	// goroutine waits for the abandoned call to finally succeed and then unlocks it
+	// automatically. If false (the default), that goroutine is left to leak, and it is the
+	// caller's responsibility to avoid ever using the lock again; callers who cannot accept
+	// either tradeoff should use the non-context Lock/RLock instead.
+	ReapOnCancel bool
 }
 
 // Mutex returns a new Mutex with the given configuration.
@@ -89,10 +320,193 @@ func (c Config) RWMutex() *RWMutex {
 	}
 }
 
+// maxTrackedReadHolders bounds the number of concurrent readers whose holder info is kept for
+// an RWMutex with read-lock tracking enabled, so a storm of readers can't grow it unbounded.
+const maxTrackedReadHolders = 32
+
+// nextLockID and nextGroupID hand out the monotonic IDs used to order LockAll/LockAllContext
+// acquisitions and to correlate their slow-lock logs, respectively.
+var (
+	nextLockID  uint64
+	nextGroupID uint64
+)
+
 type lockTracker struct {
 	Config
 	lastSuccessfulLock *LogData
+	currentHolder      *HolderInfo
+	readHolders        []HolderInfo
 	lockLock           sync.RWMutex
+
+	idOnce sync.Once
+	id     uint64
+}
+
+// lockID returns this lock's ID in the global LockAll ordering, assigning it on first use so
+// that a *Mutex/*RWMutex built as a zero value (e.g. `&Mutex{}`) gets a unique ID just like one
+// built through Config.Mutex()/RWMutex(), instead of every zero-value lock colliding on ID 0.
+func (lt *lockTracker) lockID() uint64 {
+	lt.idOnce.Do(func() {
+		lt.id = atomic.AddUint64(&nextLockID, 1)
+	})
+	return lt.id
+}
+
+// logSlow invokes the configured StructuredLogFunction, or else the configured (or default)
+// legacy LogFunction, for a lock acquisition that has been waiting longer than the timeout,
+// attaching the current holder's info if there is one. attempt is the 1-based count of how many
+// times this acquisition has now been reported; see Config.MaxLogCount.
+func (lt *lockTracker) logSlow(logData LogData, attempt int) {
+	lt.lockLock.RLock()
+	lf := lt.LogFunction
+	slf := lt.StructuredLogFunction
+	lsl := lt.lastSuccessfulLock
+	holder := lt.currentHolder
+	lt.lockLock.RUnlock()
+	if holder != nil {
+		h := *holder
+		h.Stack = stackForGoroutine(h.GoroutineID)
+		logData.Holder = &h
+	}
+	if slf != nil {
+		slf(SlowLockEvent{
+			Annotation:  logData.Annotation,
+			CallerInfo:  logData.CallerInfo,
+			GoroutineID: logData.GoroutineID,
+			Elapsed:     time.Since(logData.StartTime),
+			Holder:      logData.Holder,
+			GroupID:     logData.GroupID,
+			Attempt:     attempt,
+		})
+		return
+	}
+	if lf == nil {
+		lf = defaultLogFunction
+	}
+	lf(logData, lsl)
+}
+
+// maxLogCount returns the cap on how many times a single slow-lock acquisition will be
+// reported: a configured Config.MaxLogCount if positive, unlimited (-1) if negative, or the
+// default of 1 (report once, rather than spamming logs for the rest of a long-held lock) if
+// left at zero.
+func (lt *lockTracker) maxLogCount() int {
+	switch {
+	case lt.MaxLogCount > 0:
+		return lt.MaxLogCount
+	case lt.MaxLogCount < 0:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// repeatInterval returns the interval between the second and subsequent slow-lock reports for
+// an acquisition, once Config.Timeout (or the default timeout) has already elapsed once.
+func (lt *lockTracker) repeatInterval(timeout time.Duration) time.Duration {
+	if lt.RepeatInterval > 0 {
+		return lt.RepeatInterval
+	}
+	return timeout
+}
+
+// clearHolder forgets the current exclusive holder, called just before the underlying lock is
+// actually released so a newly-acquiring goroutine can never have its holder record wiped by
+// this call landing after it.
+func (lt *lockTracker) clearHolder() {
+	lt.lockLock.Lock()
+	lt.currentHolder = nil
+	lt.lockLock.Unlock()
+}
+
+// holder returns the info recorded for the current exclusive holder, if any.
+func (lt *lockTracker) holder() (CallerInfo, int, bool) {
+	lt.lockLock.RLock()
+	h := lt.currentHolder
+	lt.lockLock.RUnlock()
+	if h == nil {
+		return CallerInfo{}, 0, false
+	}
+	return h.CallerInfo, h.GoroutineID, true
+}
+
+// addReadHolder records a new reader, up to maxTrackedReadHolders; beyond that, excess readers
+// are simply not tracked.
+func (lt *lockTracker) addReadHolder(h *HolderInfo) {
+	lt.lockLock.Lock()
+	if len(lt.readHolders) < maxTrackedReadHolders {
+		lt.readHolders = append(lt.readHolders, *h)
+	}
+	lt.lockLock.Unlock()
+}
+
+// removeReadHolder forgets the reader with the given goroutine ID, if it was being tracked.
+func (lt *lockTracker) removeReadHolder(goroutineID int) {
+	lt.lockLock.Lock()
+	for i := range lt.readHolders {
+		if lt.readHolders[i].GoroutineID == goroutineID {
+			lt.readHolders = append(lt.readHolders[:i], lt.readHolders[i+1:]...)
+			break
+		}
+	}
+	lt.lockLock.Unlock()
+}
+
+// readHolderSnapshot returns a copy of the currently-tracked readers.
+func (lt *lockTracker) readHolderSnapshot() []HolderInfo {
+	lt.lockLock.RLock()
+	defer lt.lockLock.RUnlock()
+	out := make([]HolderInfo, len(lt.readHolders))
+	copy(out, lt.readHolders)
+	return out
+}
+
+// slowLockTicker drives the periodic slow-lock notifications for a single lock acquisition,
+// sharing the repeat/cap bookkeeping (Config.MaxLogCount/RepeatInterval) across acquireLock,
+// acquireReadLock, and acquireLockCtx so they can't drift from each other: it fires once at
+// timeout, then at interval for as long as more reports are allowed, and after that simply
+// stops firing while the caller's own select loop keeps waiting on whatever it's waiting on.
+type slowLockTicker struct {
+	timer    *time.Timer
+	interval time.Duration
+	maxCount int
+	attempt  int
+}
+
+// newSlowLockTicker returns a slowLockTicker armed to fire once after timeout, configured from
+// lt's Config.MaxLogCount/RepeatInterval.
+func newSlowLockTicker(lt *lockTracker, timeout time.Duration) *slowLockTicker {
+	return &slowLockTicker{
+		timer:    time.NewTimer(timeout),
+		interval: lt.repeatInterval(timeout),
+		maxCount: lt.maxLogCount(),
+	}
+}
+
+// C returns the channel that receives a tick each time the ticker fires.
+func (t *slowLockTicker) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// tick is called once per value received from C: it bumps and returns the attempt count,
+// rearming the timer for another tick unless the configured cap has now been reached.
+func (t *slowLockTicker) tick() int {
+	t.attempt++
+	if t.maxCount < 0 || t.attempt < t.maxCount {
+		t.timer.Reset(t.interval)
+	}
+	return t.attempt
+}
+
+// stop stops the ticker, draining a pending tick if one was already in flight, so it can be
+// abandoned without leaking a stale value into a later select on the same channel.
+func (t *slowLockTicker) stop() {
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default:
+		}
+	}
 }
 
 func (lt *lockTracker) acquireLock(lockFunc func(), logData LogData) {
@@ -101,30 +515,137 @@ func (lt *lockTracker) acquireLock(lockFunc func(), logData LogData) {
 	if timeout == 0 {
 		timeout = defaultTimeout
 	}
-	ticker := time.NewTicker(timeout)
+	ticker := newSlowLockTicker(lt, timeout)
+	defer ticker.stop()
 	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				lt.lockLock.RLock()
-				lf := lt.LogFunction
-				lsl := lt.lastSuccessfulLock
-				lt.lockLock.RUnlock()
-				if lf == nil {
-					lf = defaultLogFunction
-				}
-				lf(logData, lsl)
-			case <-acquiredCh:
-				ticker.Stop()
-				return
+		lockFunc()
+		close(acquiredCh)
+	}()
+	for {
+		select {
+		case <-acquiredCh:
+			holder := newHolderInfo(logData.CallerInfo)
+			lt.lockLock.Lock()
+			lt.lastSuccessfulLock = &logData
+			lt.currentHolder = holder
+			lt.lockLock.Unlock()
+			return
+		case <-ticker.C():
+			// goroutineID is captured here, in the goroutine that called Lock (not the anonymous
+			// goroutine that called lockFunc), and only once a tick actually fires, so a fast,
+			// never-slow acquisition never pays for it.
+			logData.GoroutineID = goroutineID()
+			lt.logSlow(logData, ticker.tick())
+		}
+	}
+}
+
+// acquireReadLock is like acquireLock, but for a reader sharing the lock with other readers:
+// it appends to readHolders instead of replacing the single currentHolder.
+func (lt *lockTracker) acquireReadLock(lockFunc func(), logData LogData) {
+	acquiredCh := make(chan struct{})
+	timeout := lt.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ticker := newSlowLockTicker(lt, timeout)
+	defer ticker.stop()
+	go func() {
+		lockFunc()
+		close(acquiredCh)
+	}()
+	for {
+		select {
+		case <-acquiredCh:
+			holder := newHolderInfo(logData.CallerInfo)
+			lt.lockLock.Lock()
+			lt.lastSuccessfulLock = &logData
+			lt.lockLock.Unlock()
+			lt.addReadHolder(holder)
+			return
+		case <-ticker.C():
+			logData.GoroutineID = goroutineID()
+			lt.logSlow(logData, ticker.tick())
+		}
+	}
+}
+
+// acquireLockCtx is like acquireLock, but aborts and returns ctx.Err() if ctx is canceled
+// before lockFunc completes. Because the underlying sync.Mutex/sync.RWMutex Lock call cannot
+// be interrupted, lockFunc keeps running on its goroutine after cancellation; if
+// lt.ReapOnCancel is set, a reaper goroutine calls unlockFunc once lockFunc finally returns. If
+// isRead is true, a successful acquisition is recorded as a reader rather than as the single
+// exclusive holder.
+func (lt *lockTracker) acquireLockCtx(ctx context.Context, lockFunc func(), unlockFunc func(), logData LogData, isRead bool) error {
+	acquiredCh := make(chan struct{})
+	timeout := lt.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ticker := newSlowLockTicker(lt, timeout)
+	defer ticker.stop()
+	go func() {
+		lockFunc()
+		close(acquiredCh)
+	}()
+	for {
+		select {
+		case <-acquiredCh:
+			// newHolderInfo runs here, in the goroutine that called LockContext/RLockContext
+			// (not the anonymous goroutine that called lockFunc), so it correctly records the
+			// caller as the holder even though the actual Lock()/RLock() call happened
+			// elsewhere.
+			holder := newHolderInfo(logData.CallerInfo)
+			lt.lockLock.Lock()
+			lt.lastSuccessfulLock = &logData
+			if !isRead {
+				lt.currentHolder = holder
+			}
+			lt.lockLock.Unlock()
+			if isRead {
+				lt.addReadHolder(holder)
 			}
+			return nil
+		case <-ctx.Done():
+			err := ctx.Err()
+			if lt.ReapOnCancel {
+				go func() {
+					<-acquiredCh
+					unlockFunc()
+				}()
+			}
+			return err
+		case <-ticker.C():
+			// See acquireLock: captured lazily, here in the caller's own goroutine, only once a
+			// tick actually fires.
+			logData.GoroutineID = goroutineID()
+			lt.logSlow(logData, ticker.tick())
 		}
+	}
+}
+
+// lockCtxPlain is the fast path used by LockContext/RLockContext when slow-lock tracking is
+// disabled (see SetEnabled): it waits for lockFunc or ctx with no ticker, no time.Now(), and no
+// holder bookkeeping, only falling back to a reaper goroutine if reapOnCancel is set.
+func lockCtxPlain(ctx context.Context, lockFunc func(), unlockFunc func(), reapOnCancel bool) error {
+	acquiredCh := make(chan struct{})
+	go func() {
+		lockFunc()
+		close(acquiredCh)
 	}()
-	lockFunc()
-	close(acquiredCh)
-	lt.lockLock.Lock()
-	lt.lastSuccessfulLock = &logData
-	lt.lockLock.Unlock()
+	select {
+	case <-acquiredCh:
+		return nil
+	case <-ctx.Done():
+		err := ctx.Err()
+		if reapOnCancel {
+			go func() {
+				<-acquiredCh
+				unlockFunc()
+			}()
+		}
+		return err
+	}
 }
 
 // Mutex is a drop-in replacement for sync.Mutex that provides logging of slow lock acquisitions.
@@ -135,6 +656,31 @@ type Mutex struct {
 
 // Lock locks m.  If the lock is not acquired before the timeout, logs will be generated.
 func (m *Mutex) Lock() {
+	if !trackingEnabled.Load() {
+		m.mut.Lock()
+		return
+	}
+	ld := LogData{
+		Annotation: m.Annotation,
+		StartTime:  time.Now(),
+	}
+	_, file, line, ok := runtime.Caller(1)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	m.acquireLock(func() { m.mut.Lock() }, ld)
+}
+
+// LockContext locks m, honoring ctx cancellation while waiting to acquire the lock.  If ctx
+// is canceled before the lock is acquired, LockContext returns ctx.Err() without holding the
+// lock.  See Config.ReapOnCancel for what happens to the abandoned lock attempt.
+func (m *Mutex) LockContext(ctx context.Context) error {
+	if !trackingEnabled.Load() {
+		return lockCtxPlain(ctx, func() { m.mut.Lock() }, m.mut.Unlock, m.ReapOnCancel)
+	}
 	ld := LogData{
 		Annotation: m.Annotation,
 		StartTime:  time.Now(),
@@ -146,9 +692,52 @@ func (m *Mutex) Lock() {
 			Line: line,
 		}
 	}
+	return m.acquireLockCtx(ctx, func() { m.mut.Lock() }, m.mut.Unlock, ld, false)
+}
+
+// lockGroup is like Lock, but records groupID and the group's aggregate start time as part of a
+// LockAll acquisition, so a slow-lock log can report which specific sub-lock in the group was
+// slow while still showing how long the group as a whole has been waiting.
+func (m *Mutex) lockGroup(groupID uint64, start time.Time) {
+	if !trackingEnabled.Load() {
+		m.mut.Lock()
+		return
+	}
+	ld := LogData{
+		Annotation: m.Annotation,
+		StartTime:  start,
+		GroupID:    groupID,
+	}
+	_, file, line, ok := runtime.Caller(3)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
 	m.acquireLock(func() { m.mut.Lock() }, ld)
 }
 
+// lockGroupCtx is like LockContext, but for a LockAllContext acquisition; see lockGroup.
+func (m *Mutex) lockGroupCtx(ctx context.Context, groupID uint64, start time.Time) error {
+	if !trackingEnabled.Load() {
+		return lockCtxPlain(ctx, func() { m.mut.Lock() }, m.mut.Unlock, m.ReapOnCancel)
+	}
+	ld := LogData{
+		Annotation: m.Annotation,
+		StartTime:  start,
+		GroupID:    groupID,
+	}
+	_, file, line, ok := runtime.Caller(3)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	return m.acquireLockCtx(ctx, func() { m.mut.Lock() }, m.mut.Unlock, ld, false)
+}
+
 // TryLock attempts to lock m.  Slow locks will not be tracked.
 func (m *Mutex) TryLock() bool {
 	return m.mut.TryLock()
@@ -156,9 +745,17 @@ func (m *Mutex) TryLock() bool {
 
 // Unlock unlocks m.
 func (m *Mutex) Unlock() {
+	m.clearHolder()
 	m.mut.Unlock()
 }
 
+// Holder returns the caller info and goroutine ID of the goroutine currently holding m, and
+// true, or false if m is not currently locked. A lock taken with TryLock, or while tracking is
+// disabled (see SetEnabled), records no holder, so Holder also reports false for it.
+func (m *Mutex) Holder() (CallerInfo, int, bool) {
+	return m.lockTracker.holder()
+}
+
 // RWMutex is a drop-in replacement for sync.RWMutex that provides logging of slow lock acquisitions.
 type RWMutex struct {
 	lockTracker
@@ -174,11 +771,15 @@ func (rw *RWMutex) SetTrackReadLocks(track bool) {
 
 // Lock locks rw for read/write.  If the lock is not acquired before the timeout, logs will be generated.
 func (rw *RWMutex) Lock() {
+	if !trackingEnabled.Load() {
+		rw.mut.Lock()
+		return
+	}
 	ld := LogData{
 		Annotation: rw.Annotation,
 		StartTime:  time.Now(),
 	}
-	_, file, line, ok := runtime.Caller(0)
+	_, file, line, ok := runtime.Caller(1)
 	if ok {
 		ld.CallerInfo = &CallerInfo{
 			File: file,
@@ -188,6 +789,70 @@ func (rw *RWMutex) Lock() {
 	rw.acquireLock(func() { rw.mut.Lock() }, ld)
 }
 
+// LockContext locks rw for read/write, honoring ctx cancellation while waiting to acquire the
+// lock.  If ctx is canceled before the lock is acquired, LockContext returns ctx.Err()
+// without holding the lock.  See Config.ReapOnCancel for what happens to the abandoned lock
+// attempt.
+func (rw *RWMutex) LockContext(ctx context.Context) error {
+	if !trackingEnabled.Load() {
+		return lockCtxPlain(ctx, func() { rw.mut.Lock() }, rw.mut.Unlock, rw.ReapOnCancel)
+	}
+	ld := LogData{
+		Annotation: rw.Annotation,
+		StartTime:  time.Now(),
+	}
+	_, file, line, ok := runtime.Caller(1)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	return rw.acquireLockCtx(ctx, func() { rw.mut.Lock() }, rw.mut.Unlock, ld, false)
+}
+
+// lockGroup is like Lock, but records groupID and the group's aggregate start time as part of a
+// LockAll acquisition; see Mutex.lockGroup.
+func (rw *RWMutex) lockGroup(groupID uint64, start time.Time) {
+	if !trackingEnabled.Load() {
+		rw.mut.Lock()
+		return
+	}
+	ld := LogData{
+		Annotation: rw.Annotation,
+		StartTime:  start,
+		GroupID:    groupID,
+	}
+	_, file, line, ok := runtime.Caller(3)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	rw.acquireLock(func() { rw.mut.Lock() }, ld)
+}
+
+// lockGroupCtx is like LockContext, but for a LockAllContext acquisition; see Mutex.lockGroup.
+func (rw *RWMutex) lockGroupCtx(ctx context.Context, groupID uint64, start time.Time) error {
+	if !trackingEnabled.Load() {
+		return lockCtxPlain(ctx, func() { rw.mut.Lock() }, rw.mut.Unlock, rw.ReapOnCancel)
+	}
+	ld := LogData{
+		Annotation: rw.Annotation,
+		StartTime:  start,
+		GroupID:    groupID,
+	}
+	_, file, line, ok := runtime.Caller(3)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	return rw.acquireLockCtx(ctx, func() { rw.mut.Lock() }, rw.mut.Unlock, ld, false)
+}
+
 // TryLock attempts to lock rw for read/write.  Slow locks will not be tracked.
 func (rw *RWMutex) TryLock() bool {
 	return rw.mut.TryLock()
@@ -195,12 +860,21 @@ func (rw *RWMutex) TryLock() bool {
 
 // Unlock releases the read/write lock on rw.
 func (rw *RWMutex) Unlock() {
+	rw.clearHolder()
 	rw.mut.Unlock()
 }
 
+// Holder returns the caller info and goroutine ID of the goroutine currently holding rw for
+// read/write, and true, or false if rw is not currently write-locked. A write lock taken with
+// TryLock, or while tracking is disabled (see SetEnabled), records no holder, so Holder also
+// reports false for it.
+func (rw *RWMutex) Holder() (CallerInfo, int, bool) {
+	return rw.lockTracker.holder()
+}
+
 // RLock locks rw for read.  If the lock is not acquired before the timeout, logs will be generated.
 func (rw *RWMutex) RLock() {
-	if !rw.trackReadLocks {
+	if !rw.trackReadLocks || !trackingEnabled.Load() {
 		rw.mut.RLock()
 		return
 	}
@@ -208,14 +882,37 @@ func (rw *RWMutex) RLock() {
 		Annotation: rw.Annotation,
 		StartTime:  time.Now(),
 	}
-	_, file, line, ok := runtime.Caller(0)
+	_, file, line, ok := runtime.Caller(1)
 	if ok {
 		ld.CallerInfo = &CallerInfo{
 			File: file,
 			Line: line,
 		}
 	}
-	rw.acquireLock(func() { rw.mut.RLock() }, ld)
+	rw.acquireReadLock(func() { rw.mut.RLock() }, ld)
+}
+
+// RLockContext locks rw for read, honoring ctx cancellation while waiting to acquire the
+// lock.  If ctx is canceled before the lock is acquired, RLockContext returns ctx.Err()
+// without holding the lock.  Unlike RLock, RLockContext always tracks the acquisition
+// regardless of SetTrackReadLocks, since honoring ctx requires attempting the lock on a
+// separate goroutine.  See Config.ReapOnCancel for what happens to the abandoned attempt.
+func (rw *RWMutex) RLockContext(ctx context.Context) error {
+	if !trackingEnabled.Load() {
+		return lockCtxPlain(ctx, func() { rw.mut.RLock() }, rw.mut.RUnlock, rw.ReapOnCancel)
+	}
+	ld := LogData{
+		Annotation: rw.Annotation,
+		StartTime:  time.Now(),
+	}
+	_, file, line, ok := runtime.Caller(1)
+	if ok {
+		ld.CallerInfo = &CallerInfo{
+			File: file,
+			Line: line,
+		}
+	}
+	return rw.acquireLockCtx(ctx, func() { rw.mut.RLock() }, rw.mut.RUnlock, ld, true)
 }
 
 // TryRLock attempts to lock rw for read.  Slow locks will not be tracked.
@@ -225,9 +922,19 @@ func (rw *RWMutex) TryRLock() bool {
 
 // RUnlock releases the read lock on rw.
 func (rw *RWMutex) RUnlock() {
+	// Always attempt to forget this reader, even if trackReadLocks is currently false: a read
+	// lock acquired through RLockContext is tracked regardless of that setting.
+	rw.removeReadHolder(goroutineID())
 	rw.mut.RUnlock()
 }
 
+// ReadHolders returns a snapshot of the readers currently tracked for rw. Tracking must have
+// been enabled with SetTrackReadLocks(true), or via RLockContext, for this to return anything;
+// at most maxTrackedReadHolders concurrent readers are tracked at a time.
+func (rw *RWMutex) ReadHolders() []HolderInfo {
+	return rw.readHolderSnapshot()
+}
+
 // RLocker returns a Locker interface that implements
 // the Lock and Unlock methods by calling rw.RLock and rw.RUnlock.
 func (rw *RWMutex) RLocker() sync.Locker {
@@ -238,3 +945,108 @@ type rlocker RWMutex
 
 func (r *rlocker) Lock()   { (*RWMutex)(r).RLock() }
 func (r *rlocker) Unlock() { (*RWMutex)(r).RUnlock() }
+
+// idLocker is implemented by *Mutex and *RWMutex (via the promoted lockTracker.lockID), giving
+// LockAll/LockAllContext a well-defined global order to lock them in. sync.Locker values that
+// don't implement it have no such order; see groupMembers.
+type idLocker interface {
+	sync.Locker
+	lockID() uint64
+}
+
+// groupMember pairs a lock passed to LockAll/LockAllContext with the ID used to order it.
+type groupMember struct {
+	id     uint64
+	locker sync.Locker
+}
+
+// groupMembers sorts locks into the canonical order LockAll/LockAllContext acquires them in, so
+// that two callers locking an overlapping set of *Mutex/*RWMutex values can never deadlock
+// against each other. Locks that don't implement idLocker (plain sync.Locker values) have no ID
+// of their own; they sort before every tracked lock and keep their relative order from locks,
+// so callers mixing such locks into a group are responsible for passing them consistently.
+func groupMembers(locks []sync.Locker) []groupMember {
+	members := make([]groupMember, len(locks))
+	for i, l := range locks {
+		var id uint64
+		if idl, ok := l.(idLocker); ok {
+			id = idl.lockID()
+		}
+		members[i] = groupMember{id: id, locker: l}
+	}
+	sort.SliceStable(members, func(i, j int) bool { return members[i].id < members[j].id })
+	return members
+}
+
+// lockGroupMember acquires a single member of a LockAll group, recording groupID/start against
+// it if it's a *Mutex/*RWMutex, or just calling Lock() otherwise.
+func lockGroupMember(l sync.Locker, groupID uint64, start time.Time) {
+	switch t := l.(type) {
+	case *Mutex:
+		t.lockGroup(groupID, start)
+	case *RWMutex:
+		t.lockGroup(groupID, start)
+	default:
+		l.Lock()
+	}
+}
+
+// lockGroupMemberCtx is like lockGroupMember, but for LockAllContext.
+func lockGroupMemberCtx(ctx context.Context, l sync.Locker, groupID uint64, start time.Time) error {
+	switch t := l.(type) {
+	case *Mutex:
+		return t.lockGroupCtx(ctx, groupID, start)
+	case *RWMutex:
+		return t.lockGroupCtx(ctx, groupID, start)
+	default:
+		return lockCtxPlain(ctx, l.Lock, l.Unlock, false)
+	}
+}
+
+// LockAll acquires every lock in locks, in a canonical order derived from each *Mutex/*RWMutex's
+// ID, which is assigned the first time that lock goes through LockAll/LockAllContext (see
+// lockID and groupMembers), and returns a function that releases them all in reverse
+// order. Acquiring overlapping sets of locks through LockAll, however many goroutines do it and
+// in whatever order they're passed in, can never deadlock against the others, since every
+// caller ends up locking the shared subset in the same order.
+//
+// The acquisition is logged as a single group: each sub-lock's slow-lock log (if any) carries
+// the same GroupID and the group's aggregate start time, so they can be correlated.
+func LockAll(locks ...sync.Locker) func() {
+	members := groupMembers(locks)
+	groupID := atomic.AddUint64(&nextGroupID, 1)
+	start := time.Now()
+	for _, m := range members {
+		lockGroupMember(m.locker, groupID, start)
+	}
+	return func() {
+		for i := len(members) - 1; i >= 0; i-- {
+			members[i].locker.Unlock()
+		}
+	}
+}
+
+// LockAllContext is like LockAll, but honors ctx cancellation while waiting to acquire any of
+// the locks. If ctx is canceled (or expires) before every lock is acquired, every lock
+// successfully acquired so far is released, in reverse order, and LockAllContext returns
+// ctx.Err() instead of a release function.
+func LockAllContext(ctx context.Context, locks ...sync.Locker) (func(), error) {
+	members := groupMembers(locks)
+	groupID := atomic.AddUint64(&nextGroupID, 1)
+	start := time.Now()
+	acquired := make([]sync.Locker, 0, len(members))
+	for _, m := range members {
+		if err := lockGroupMemberCtx(ctx, m.locker, groupID, start); err != nil {
+			for i := len(acquired) - 1; i >= 0; i-- {
+				acquired[i].Unlock()
+			}
+			return nil, err
+		}
+		acquired = append(acquired, m.locker)
+	}
+	return func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			acquired[i].Unlock()
+		}
+	}, nil
+}
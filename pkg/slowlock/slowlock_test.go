@@ -1,7 +1,9 @@
 package slowlock
 
 import (
+	"context"
 	"math/rand"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -94,3 +96,440 @@ func TestHammerWithSlow(t *testing.T) {
 		assert.Less(t, mw, 200*time.Millisecond)
 	}
 }
+
+func TestLockContextSuccess(t *testing.T) {
+	cfg := Config{Timeout: time.Second}
+	m := cfg.Mutex()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, m.LockContext(ctx))
+	m.Unlock()
+
+	rw := cfg.RWMutex()
+	assert.NoError(t, rw.LockContext(ctx))
+	rw.Unlock()
+	assert.NoError(t, rw.RLockContext(ctx))
+	rw.RUnlock()
+}
+
+func TestLockContextCancel(t *testing.T) {
+	cfg := Config{Timeout: time.Second}
+	for _, m := range []sync.Locker{cfg.Mutex(), cfg.RWMutex()} {
+		m.Lock()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		var err error
+		switch l := m.(type) {
+		case *Mutex:
+			err = l.LockContext(ctx)
+		case *RWMutex:
+			err = l.LockContext(ctx)
+		}
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		m.Unlock()
+	}
+}
+
+func TestLockContextReapOnCancel(t *testing.T) {
+	cfg := Config{Timeout: time.Second, ReapOnCancel: true}
+	m := cfg.Mutex()
+	m.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := m.LockContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	m.Unlock()
+	// The reaper should acquire and release the lock on our behalf once it becomes available.
+	assert.Eventually(t, func() bool {
+		return m.TryLock()
+	}, time.Second, time.Millisecond)
+	m.Unlock()
+}
+
+func TestHolder(t *testing.T) {
+	m := &Mutex{}
+	_, _, ok := m.Holder()
+	assert.False(t, ok)
+
+	m.Lock()
+	ci, gid, ok := m.Holder()
+	assert.True(t, ok)
+	assert.Equal(t, "slowlock_test.go", filepath.Base(ci.File))
+	assert.Equal(t, goroutineID(), gid)
+	m.Unlock()
+
+	_, _, ok = m.Holder()
+	assert.False(t, ok)
+}
+
+func TestHolderRWMutex(t *testing.T) {
+	rw := &RWMutex{}
+	_, _, ok := rw.Holder()
+	assert.False(t, ok)
+
+	rw.Lock()
+	ci, gid, ok := rw.Holder()
+	assert.True(t, ok)
+	assert.Equal(t, "slowlock_test.go", filepath.Base(ci.File))
+	assert.Equal(t, goroutineID(), gid)
+	rw.Unlock()
+
+	_, _, ok = rw.Holder()
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, rw.LockContext(ctx))
+	ci, gid, ok = rw.Holder()
+	assert.True(t, ok)
+	assert.Equal(t, "slowlock_test.go", filepath.Base(ci.File))
+	assert.Equal(t, goroutineID(), gid)
+	rw.Unlock()
+
+	rw.SetTrackReadLocks(true)
+	rw.RLock()
+	holders := rw.ReadHolders()
+	assert.Len(t, holders, 1)
+	assert.Equal(t, "slowlock_test.go", filepath.Base(holders[0].CallerInfo.File))
+	rw.RUnlock()
+
+	assert.NoError(t, rw.RLockContext(ctx))
+	holders = rw.ReadHolders()
+	assert.Len(t, holders, 1)
+	assert.Equal(t, "slowlock_test.go", filepath.Base(holders[0].CallerInfo.File))
+	rw.RUnlock()
+}
+
+func TestReadHolders(t *testing.T) {
+	rw := &RWMutex{}
+	rw.SetTrackReadLocks(true)
+
+	rw.RLock()
+	holders := rw.ReadHolders()
+	assert.Len(t, holders, 1)
+	assert.Equal(t, goroutineID(), holders[0].GoroutineID)
+	rw.RUnlock()
+	assert.Empty(t, rw.ReadHolders())
+
+	done := make(chan struct{})
+	rw.RLock()
+	go func() {
+		rw.RLock()
+		rw.RUnlock()
+		close(done)
+	}()
+	<-done
+	rw.RUnlock()
+}
+
+func TestLockAllOrdering(t *testing.T) {
+	a, b := &Mutex{}, &Mutex{}
+	// lockID() is only assigned the first time a lock goes through LockAll/LockAllContext, so
+	// this LockAll(a, b) both establishes a's ID before b's and exercises that assignment;
+	// every later call (regardless of the order its arguments are passed in) must then lock a
+	// before b.
+	LockAll(a, b)()
+
+	const iterations = 200
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			unlock := LockAll(a, b)
+			unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			unlock := LockAll(b, a)
+			unlock()
+		}
+	}()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("LockAll deadlocked")
+	}
+}
+
+func TestLockAllGroupCallerInfo(t *testing.T) {
+	var events []SlowLockEvent
+	var evLock sync.Mutex
+	cfg := Config{
+		Timeout: 10 * time.Millisecond,
+		StructuredLogFunction: func(event SlowLockEvent) {
+			evLock.Lock()
+			defer evLock.Unlock()
+			events = append(events, event)
+		},
+	}
+	m, rw := cfg.Mutex(), cfg.RWMutex()
+	m.Lock()
+	rw.Lock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock := LockAll(m, rw)
+		unlock()
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	m.Unlock()
+	rw.Unlock()
+	<-done
+
+	evLock.Lock()
+	defer evLock.Unlock()
+	assert.NotEmpty(t, events)
+	for _, event := range events {
+		if assert.NotNil(t, event.CallerInfo) {
+			assert.Equal(t, "slowlock_test.go", filepath.Base(event.CallerInfo.File))
+		}
+	}
+}
+
+func TestLockAllReleasesAcquiredOnCancel(t *testing.T) {
+	cfg := Config{Timeout: time.Second}
+	a := cfg.Mutex()
+	b := cfg.Mutex()
+	b.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	unlock, err := LockAllContext(ctx, a, b)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, unlock)
+
+	// a should have been released again since b could never be acquired.
+	assert.True(t, a.TryLock())
+	a.Unlock()
+	b.Unlock()
+}
+
+func TestLockAllContextPlainLockerHonorsCancel(t *testing.T) {
+	cfg := Config{Timeout: time.Second}
+	a := cfg.Mutex()
+	var plain sync.Mutex
+	plain.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	unlock, err := LockAllContext(ctx, a, &plain)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, unlock)
+
+	// The plain locker has no ID, so it sorts before a and is tried first; since it could never
+	// be acquired, a was never locked and is still free.
+	assert.True(t, a.TryLock())
+	a.Unlock()
+	plain.Unlock()
+}
+
+func TestSetEnabledDisablesTracking(t *testing.T) {
+	assert.True(t, Enabled())
+	SetEnabled(false)
+	defer SetEnabled(true)
+
+	var logged bool
+	logMsgLock := sync.Mutex{}
+	cfg := Config{
+		Timeout: 10 * time.Millisecond,
+		LogFunction: func(data LogData, lastSuccessful *LogData) {
+			logMsgLock.Lock()
+			logged = true
+			logMsgLock.Unlock()
+		},
+	}
+	m := cfg.Mutex()
+	m.Lock()
+	// While disabled, Lock doesn't track the holder, so Holder() reports nothing.
+	_, _, ok := m.Holder()
+	assert.False(t, ok)
+	time.Sleep(30 * time.Millisecond)
+	m.Unlock()
+
+	logMsgLock.Lock()
+	defer logMsgLock.Unlock()
+	assert.False(t, logged)
+}
+
+func TestSetEnabledDisablesLockContext(t *testing.T) {
+	SetEnabled(false)
+	defer SetEnabled(true)
+
+	cfg := Config{Timeout: time.Second}
+	m := cfg.Mutex()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, m.LockContext(ctx))
+	m.Unlock()
+
+	m.Lock()
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel2()
+	err := m.LockContext(ctx2)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	m.Unlock()
+}
+
+func TestSetEnabledDisablesLockAll(t *testing.T) {
+	SetEnabled(false)
+	defer SetEnabled(true)
+
+	var logged bool
+	logMsgLock := sync.Mutex{}
+	cfg := Config{
+		Timeout: 10 * time.Millisecond,
+		LogFunction: func(data LogData, lastSuccessful *LogData) {
+			logMsgLock.Lock()
+			logged = true
+			logMsgLock.Unlock()
+		},
+	}
+	a, b := cfg.Mutex(), cfg.Mutex()
+	unlock := LockAll(a, b)
+	// While disabled, LockAll doesn't track either lock, so Holder() reports nothing.
+	_, _, ok := a.Holder()
+	assert.False(t, ok)
+	time.Sleep(30 * time.Millisecond)
+	unlock()
+
+	logMsgLock.Lock()
+	defer logMsgLock.Unlock()
+	assert.False(t, logged)
+}
+
+func TestStructuredLogFunction(t *testing.T) {
+	var events []SlowLockEvent
+	var evLock sync.Mutex
+	cfg := Config{
+		Timeout: 10 * time.Millisecond,
+		StructuredLogFunction: func(event SlowLockEvent) {
+			evLock.Lock()
+			defer evLock.Unlock()
+			events = append(events, event)
+		},
+	}
+	m := cfg.Mutex()
+	m.Lock()
+	go func() {
+		time.Sleep(35 * time.Millisecond)
+		m.Unlock()
+	}()
+	//nolint: all    // golangci-lint warns about empty critical section
+	{
+		m.Lock()
+		m.Unlock()
+	}
+
+	evLock.Lock()
+	defer evLock.Unlock()
+	// MaxLogCount defaults to 1: only the first tick should have been reported, even though the
+	// wait spanned more than one Timeout.
+	assert.Len(t, events, 1)
+	assert.Equal(t, 1, events[0].Attempt)
+	assert.Equal(t, goroutineID(), events[0].GoroutineID)
+}
+
+func TestFastLockNeverLogs(t *testing.T) {
+	var events []SlowLockEvent
+	var evLock sync.Mutex
+	cfg := Config{
+		Timeout: time.Second,
+		StructuredLogFunction: func(event SlowLockEvent) {
+			evLock.Lock()
+			defer evLock.Unlock()
+			events = append(events, event)
+		},
+	}
+	m := cfg.Mutex()
+	// A fast, never-slow acquisition should never reach logSlow, so the waiter's goroutine ID
+	// (captured only on the tick path) is never taken for it either; goroutineID() still runs
+	// once, to record the holder.
+	m.Lock()
+	m.Unlock()
+
+	evLock.Lock()
+	defer evLock.Unlock()
+	assert.Empty(t, events)
+}
+
+func TestMaxLogCountRepeats(t *testing.T) {
+	var attempts []int
+	var evLock sync.Mutex
+	cfg := Config{
+		Timeout:     10 * time.Millisecond,
+		MaxLogCount: 3,
+		StructuredLogFunction: func(event SlowLockEvent) {
+			evLock.Lock()
+			defer evLock.Unlock()
+			attempts = append(attempts, event.Attempt)
+		},
+	}
+	m := cfg.Mutex()
+	m.Lock()
+	go func() {
+		time.Sleep(65 * time.Millisecond)
+		m.Unlock()
+	}()
+	//nolint: all    // golangci-lint warns about empty critical section
+	{
+		m.Lock()
+		m.Unlock()
+	}
+
+	evLock.Lock()
+	defer evLock.Unlock()
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+}
+
+func TestLogDataWithHolder(t *testing.T) {
+	var (
+		loggedLock sync.Mutex
+		logged     LogData
+	)
+	loggedCh := make(chan struct{}, 1)
+	cfg := Config{
+		Timeout: 20 * time.Millisecond,
+		LogFunction: func(data LogData, lastSuccessful *LogData) {
+			loggedLock.Lock()
+			logged = data
+			loggedLock.Unlock()
+			select {
+			case loggedCh <- struct{}{}:
+			default:
+			}
+		},
+	}
+	m := cfg.Mutex()
+	holderGIDCh := make(chan int, 1)
+	holderDone := make(chan struct{})
+	go func() {
+		m.Lock()
+		holderGIDCh <- goroutineID()
+		time.Sleep(60 * time.Millisecond)
+		m.Unlock()
+		close(holderDone)
+	}()
+	holderGID := <-holderGIDCh
+	// Contend for the lock that the goroutine above is holding, so the ticker logs it as the
+	// current holder while we wait.
+	m.Lock()
+	m.Unlock()
+	<-holderDone
+	<-loggedCh
+
+	loggedLock.Lock()
+	defer loggedLock.Unlock()
+	assert.NotNil(t, logged.Holder)
+	assert.Equal(t, holderGID, logged.Holder.GoroutineID)
+	assert.NotEqual(t, goroutineID(), logged.Holder.GoroutineID)
+}
@@ -0,0 +1,125 @@
+package slowlock
+
+import (
+	"runtime"
+	"time"
+)
+
+// TypedMutex is a Mutex that owns a guarded value of type T, so that locking the mutex and
+// getting at the value it protects can't be done out of step with each other.
+type TypedMutex[T any] struct {
+	Mutex
+	value T
+}
+
+// NewTypedMutex returns a new TypedMutex[T] with the given configuration, guarding initial.
+func NewTypedMutex[T any](c Config, initial T) *TypedMutex[T] {
+	return &TypedMutex[T]{
+		Mutex: *c.Mutex(),
+		value: initial,
+	}
+}
+
+// Lock locks tm (with the same slow-lock tracking as Mutex.Lock) and returns a pointer to the
+// guarded value.
+func (tm *TypedMutex[T]) Lock() *T {
+	if !trackingEnabled.Load() {
+		tm.mut.Lock()
+		return &tm.value
+	}
+	ld := LogData{Annotation: tm.Annotation, StartTime: time.Now()}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		ld.CallerInfo = &CallerInfo{File: file, Line: line}
+	}
+	tm.acquireLock(func() { tm.mut.Lock() }, ld)
+	return &tm.value
+}
+
+// TryLock attempts to lock tm.  On success it returns a pointer to the guarded value and true;
+// on failure, nil and false.  Slow locks will not be tracked.
+func (tm *TypedMutex[T]) TryLock() (*T, bool) {
+	if !tm.Mutex.TryLock() {
+		return nil, false
+	}
+	return &tm.value, true
+}
+
+// Unlock unlocks tm.  If newValue is given, it replaces the guarded value before unlocking.
+func (tm *TypedMutex[T]) Unlock(newValue ...T) {
+	if len(newValue) > 0 {
+		tm.value = newValue[0]
+	}
+	tm.Mutex.Unlock()
+}
+
+// TypedRWMutex is an RWMutex that owns a guarded value of type T, so that locking the mutex and
+// getting at the value it protects can't be done out of step with each other.
+type TypedRWMutex[T any] struct {
+	RWMutex
+	value T
+}
+
+// NewTypedRWMutex returns a new TypedRWMutex[T] with the given configuration, guarding initial.
+func NewTypedRWMutex[T any](c Config, initial T) *TypedRWMutex[T] {
+	return &TypedRWMutex[T]{
+		RWMutex: *c.RWMutex(),
+		value:   initial,
+	}
+}
+
+// Lock locks trw for read/write (with the same slow-lock tracking as RWMutex.Lock) and returns
+// a pointer to the guarded value.
+func (trw *TypedRWMutex[T]) Lock() *T {
+	if !trackingEnabled.Load() {
+		trw.mut.Lock()
+		return &trw.value
+	}
+	ld := LogData{Annotation: trw.Annotation, StartTime: time.Now()}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		ld.CallerInfo = &CallerInfo{File: file, Line: line}
+	}
+	trw.acquireLock(func() { trw.mut.Lock() }, ld)
+	return &trw.value
+}
+
+// TryLock attempts to lock trw for read/write.  On success it returns a pointer to the guarded
+// value and true; on failure, nil and false.  Slow locks will not be tracked.
+func (trw *TypedRWMutex[T]) TryLock() (*T, bool) {
+	if !trw.RWMutex.TryLock() {
+		return nil, false
+	}
+	return &trw.value, true
+}
+
+// Unlock unlocks trw.  If newValue is given, it replaces the guarded value before unlocking.
+func (trw *TypedRWMutex[T]) Unlock(newValue ...T) {
+	if len(newValue) > 0 {
+		trw.value = newValue[0]
+	}
+	trw.RWMutex.Unlock()
+}
+
+// RLock locks trw for read (with the same slow-lock tracking as RWMutex.RLock) and returns a
+// pointer to the guarded value.  Callers must treat the value as read-only: RLock doesn't
+// prevent a concurrent reader from observing the same pointer.
+func (trw *TypedRWMutex[T]) RLock() *T {
+	if !trw.trackReadLocks || !trackingEnabled.Load() {
+		trw.mut.RLock()
+		return &trw.value
+	}
+	ld := LogData{Annotation: trw.Annotation, StartTime: time.Now()}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		ld.CallerInfo = &CallerInfo{File: file, Line: line}
+	}
+	trw.acquireReadLock(func() { trw.mut.RLock() }, ld)
+	return &trw.value
+}
+
+// TryRLock attempts to lock trw for read.  On success it returns a pointer to the guarded
+// value and true; on failure, nil and false.  Slow locks will not be tracked.
+func (trw *TypedRWMutex[T]) TryRLock() (*T, bool) {
+	if !trw.RWMutex.TryRLock() {
+		return nil, false
+	}
+	return &trw.value, true
+}
@@ -0,0 +1,74 @@
+package slowlock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedMutex(t *testing.T) {
+	tm := NewTypedMutex(Config{Timeout: time.Second}, 0)
+
+	v := tm.Lock()
+	*v++
+	tm.Unlock()
+
+	v = tm.Lock()
+	assert.Equal(t, 1, *v)
+	tm.Unlock(42)
+
+	v, ok := tm.TryLock()
+	assert.True(t, ok)
+	assert.Equal(t, 42, *v)
+	tm.Unlock()
+
+	tm.Lock()
+	_, ok = tm.TryLock()
+	assert.False(t, ok)
+	tm.Unlock()
+}
+
+func TestTypedMutexHammer(t *testing.T) {
+	tm := NewTypedMutex(Config{}, 0)
+	wg := sync.WaitGroup{}
+	const goroutines, iterations = 10, 1000
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				v := tm.Lock()
+				*v++
+				tm.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	v := tm.Lock()
+	assert.Equal(t, goroutines*iterations, *v)
+	tm.Unlock()
+}
+
+func TestTypedRWMutex(t *testing.T) {
+	trw := NewTypedRWMutex(Config{Timeout: time.Second}, "initial")
+
+	v := trw.Lock()
+	assert.Equal(t, "initial", *v)
+	trw.Unlock("updated")
+
+	rv := trw.RLock()
+	assert.Equal(t, "updated", *rv)
+	trw.RUnlock()
+
+	rv, ok := trw.TryRLock()
+	assert.True(t, ok)
+	assert.Equal(t, "updated", *rv)
+	trw.RUnlock()
+
+	wv, ok := trw.TryLock()
+	assert.True(t, ok)
+	assert.Equal(t, "updated", *wv)
+	trw.Unlock()
+}